@@ -0,0 +1,164 @@
+package src
+
+import "hash/adler32"
+
+// deltaEncode - produces a Git-format delta (a varint source size, a
+// varint target size, then copy/insert instructions) that reconstructs
+// target when applied against base. Matches in target of at least
+// minMatch bytes become copy instructions; everything else is emitted as
+// literal insert bytes. Returns a delta no smaller than literally
+// inserting target whole when base and target share nothing useful.
+func deltaEncode(base []byte, target []byte, minMatch int) []byte {
+	if minMatch < 4 {
+		minMatch = 4
+	}
+
+	index := indexBlocks(base, minMatch)
+
+	out := append(writeDeltaVarint(uint64(len(base))), writeDeltaVarint(uint64(len(target)))...)
+
+	var literal []byte
+	flushLiteral := func() {
+		for len(literal) > 0 {
+			n := len(literal)
+			if n > 0x7f {
+				n = 0x7f
+			}
+			out = append(out, byte(n))
+			out = append(out, literal[:n]...)
+			literal = literal[n:]
+		}
+	}
+
+	for i := 0; i < len(target); {
+		bestOff, bestLen := -1, 0
+
+		if i+minMatch <= len(target) {
+			h := adler32.Checksum(target[i : i+minMatch])
+			for _, off := range index[h] {
+				if l := matchLen(base[off:], target[i:]); l > bestLen {
+					bestOff, bestLen = off, l
+				}
+			}
+		}
+
+		if bestLen >= minMatch {
+			flushLiteral()
+			emitCopy(&out, bestOff, bestLen)
+			i += bestLen
+			continue
+		}
+
+		literal = append(literal, target[i])
+		i++
+	}
+	flushLiteral()
+
+	return out
+}
+
+// maxChainPerHash - the most candidate offsets "indexBlocks" keeps per
+// Adler-32 bucket. Without a cap, a target with long repetitive runs
+// (padding, sparse files, duplicate chunks) hashes every position in
+// base to the same bucket, and the candidate loop in "deltaEncode"
+// degrades to O(len(base) * len(target)). Keeping only the most recent
+// offsets, like real delta indexers do, bounds the scan per target
+// position to a constant.
+const maxChainPerHash = 64
+
+// indexBlocks - maps every minMatch-byte block's Adler-32 checksum to
+// the offsets in base where it starts, so "deltaEncode" can find match
+// candidates in O(1) instead of scanning base for every target position.
+// Each bucket retains at most the "maxChainPerHash" most recent offsets.
+func indexBlocks(base []byte, minMatch int) map[uint32][]int {
+	index := make(map[uint32][]int)
+	for i := 0; i+minMatch <= len(base); i++ {
+		h := adler32.Checksum(base[i : i+minMatch])
+		bucket := index[h]
+		if len(bucket) >= maxChainPerHash {
+			bucket = bucket[1:]
+		}
+		index[h] = append(bucket, i)
+	}
+	return index
+}
+
+// matchLen - how many leading bytes a and b have in common, capped at
+// the 3-byte copy-instruction size field's maximum (0xFFFFFF).
+func matchLen(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n > 0xFFFFFF {
+		n = 0xFFFFFF
+	}
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// emitCopy - appends a copy instruction (bits 0-3 select which of the
+// four offset bytes follow, bits 4-6 select which of the three size
+// bytes follow) to out.
+func emitCopy(out *[]byte, offset, size int) {
+	cmd := byte(0x80)
+	var payload []byte
+
+	for k := uint(0); k < 4; k++ {
+		b := byte(offset >> (8 * k))
+		if b != 0 {
+			cmd |= 1 << k
+			payload = append(payload, b)
+		}
+	}
+	for k := uint(0); k < 3; k++ {
+		b := byte(size >> (8 * k))
+		if b != 0 {
+			cmd |= 1 << (4 + k)
+			payload = append(payload, b)
+		}
+	}
+
+	*out = append(*out, cmd)
+	*out = append(*out, payload...)
+}
+
+// writeDeltaVarint - encodes v as the plain 7-bit-per-byte little-endian
+// varint "readDeltaVarint" decodes.
+func writeDeltaVarint(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			break
+		}
+	}
+	return out
+}
+
+// encodeOfsDeltaOffset - encodes offset as the biased big-endian varint
+// "readOfsDeltaOffset" decodes.
+func encodeOfsDeltaOffset(offset int64) []byte {
+	var rev []byte
+	rev = append(rev, byte(offset&0x7f))
+	offset >>= 7
+	for offset > 0 {
+		offset--
+		rev = append(rev, byte(0x80|(offset&0x7f)))
+		offset >>= 7
+	}
+
+	out := make([]byte, len(rev))
+	for i, b := range rev {
+		out[len(rev)-1-i] = b
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package src
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeltaEncodeRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		base   string
+		target string
+	}{
+		{"identical", "the quick brown fox jumps over the lazy dog", "the quick brown fox jumps over the lazy dog"},
+		{"appended", "the quick brown fox", "the quick brown fox jumps over the lazy dog"},
+		{"modified middle", "the quick brown fox jumps over the lazy dog", "the quick RED fox jumps over the lazy dog"},
+		{"empty base", "", "a brand new target"},
+		{"empty target", "a brand new target", ""},
+		{"no overlap", "aaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbb"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			delta := deltaEncode([]byte(c.base), []byte(c.target), 16)
+
+			got, err := applyDelta([]byte(c.base), delta)
+			if err != nil {
+				t.Fatalf("applyDelta: %v", err)
+			}
+			if !bytes.Equal(got, []byte(c.target)) {
+				t.Fatalf("applyDelta round-trip mismatch: got %q want %q", got, c.target)
+			}
+		})
+	}
+}
+
+// TestDeltaEncodeRepetitiveRunRoundTrip exercises the case maxChainPerHash
+// exists for: a base with a long run of identical blocks. Correctness
+// must hold even though most candidate offsets for a match are dropped.
+func TestDeltaEncodeRepetitiveRunRoundTrip(t *testing.T) {
+	base := bytes.Repeat([]byte{0x00}, 5000)
+	target := append(bytes.Repeat([]byte{0x00}, 4000), []byte("trailing literal bytes")...)
+
+	delta := deltaEncode(base, target, 16)
+
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("applyDelta: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Fatalf("applyDelta round-trip mismatch on repetitive input")
+	}
+}
@@ -0,0 +1,149 @@
+package src
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// MigrateToSHA256 - rewrites every object reachable from src into dst
+// under "HashSHA256", remapping the sha1 references "tree"/"parent"
+// (commits) and "object" (tags) point at, and the 20-byte sha1s inside
+// tree entries. src is read but never modified; dst should be an empty
+// store. Returns the sha1->sha256 id mapping it built along the way.
+//
+// Objects are migrated in dependency order (blobs and already-resolved
+// trees/commits first) since a tree or commit can only be rehashed once
+// every id it references has its sha256 counterpart known.
+func MigrateToSHA256(src ObjectStore, dst ObjectStore) (map[string]string, error) {
+	var pending []string
+	if err := src.Iter(func(sha string) error {
+		pending = append(pending, sha)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[string]string, len(pending))
+
+	for len(pending) > 0 {
+		progressed := false
+		var next []string
+
+		for _, sha := range pending {
+			obj, err := src.Read(sha)
+			if err != nil {
+				return nil, err
+			}
+
+			migrated, ok, err := migrateObject(obj, mapping)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				next = append(next, sha)
+				continue
+			}
+
+			newSha, err := dst.Write(migrated)
+			if err != nil {
+				return nil, err
+			}
+			mapping[sha] = newSha
+			progressed = true
+		}
+
+		if !progressed {
+			return nil, fmt.Errorf("hashalgo: migration stalled, %d object(s) have unresolved references", len(next))
+		}
+		pending = next
+	}
+
+	return mapping, nil
+}
+
+// migrateObject - rewrites obj's internal sha1 references using mapping,
+// returning ok=false if a reference hasn't been migrated yet.
+func migrateObject(obj GitObject, mapping map[string]string) (GitObject, bool, error) {
+	switch obj.Kind {
+	case "blob":
+		return obj, true, nil
+	case "tree":
+		data, ok, err := remapTreeEntries(obj.Data, mapping)
+		return GitObject{Kind: obj.Kind, Data: data}, ok, err
+	case "commit":
+		data, ok := remapHeaderRefs(obj.Data, []string{"tree", "parent"}, mapping)
+		return GitObject{Kind: obj.Kind, Data: data}, ok, nil
+	case "tag":
+		data, ok := remapHeaderRefs(obj.Data, []string{"object"}, mapping)
+		return GitObject{Kind: obj.Kind, Data: data}, ok, nil
+	default:
+		return GitObject{}, false, fmt.Errorf("hashalgo: unknown object kind %q", obj.Kind)
+	}
+}
+
+// remapTreeEntries - rewrites each "mode SP name NUL <sha1>" entry's
+// trailing 20-byte sha1 into the sha256 mapping gives it.
+func remapTreeEntries(data []byte, mapping map[string]string) ([]byte, bool, error) {
+	var out bytes.Buffer
+
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 {
+			return nil, false, fmt.Errorf("hashalgo: malformed tree entry")
+		}
+		nul := bytes.IndexByte(data[sp:], 0x00)
+		if nul < 0 {
+			return nil, false, fmt.Errorf("hashalgo: malformed tree entry")
+		}
+		nul += sp
+
+		if len(data) < nul+1+20 {
+			return nil, false, fmt.Errorf("hashalgo: truncated tree entry")
+		}
+
+		oldSha := hex.EncodeToString(data[nul+1 : nul+1+20])
+		newSha, ok := mapping[oldSha]
+		if !ok {
+			return nil, false, nil
+		}
+		newShaBytes, err := hex.DecodeString(newSha)
+		if err != nil {
+			return nil, false, err
+		}
+
+		out.Write(data[:nul+1])
+		out.Write(newShaBytes)
+
+		data = data[nul+1+20:]
+	}
+
+	return out.Bytes(), true, nil
+}
+
+// remapHeaderRefs - rewrites the id following any of "prefixes" at the
+// start of a header line (e.g. "tree <sha>", "parent <sha>") up to the
+// first blank line, leaving the commit/tag message untouched.
+func remapHeaderRefs(data []byte, prefixes []string, mapping map[string]string) ([]byte, bool) {
+	lines := bytes.Split(data, []byte("\n"))
+
+	for i, line := range lines {
+		if len(line) == 0 {
+			break // end of headers, remainder is the message
+		}
+
+		for _, prefix := range prefixes {
+			if !bytes.HasPrefix(line, []byte(prefix+" ")) {
+				continue
+			}
+			oldSha := string(line[len(prefix)+1:])
+			newSha, ok := mapping[oldSha]
+			if !ok {
+				return nil, false
+			}
+			lines[i] = []byte(prefix + " " + newSha)
+		}
+	}
+
+	return bytes.Join(lines, []byte("\n")), true
+}
@@ -0,0 +1,98 @@
+package src
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestMigrateToSHA256(t *testing.T) {
+	src := NewMemStore(HashSHA1)
+
+	blobSha, err := src.Write(GitObject{Kind: "blob", Data: []byte("tracked file contents")})
+	if err != nil {
+		t.Fatalf("Write blob: %v", err)
+	}
+
+	blobRaw, err := hex.DecodeString(blobSha)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	var treeData []byte
+	treeData = append(treeData, []byte("100644 file.txt\x00")...)
+	treeData = append(treeData, blobRaw...)
+
+	treeSha, err := src.Write(GitObject{Kind: "tree", Data: treeData})
+	if err != nil {
+		t.Fatalf("Write tree: %v", err)
+	}
+
+	commitData := []byte("tree " + treeSha + "\n\ninitial commit\n")
+	commitSha, err := src.Write(GitObject{Kind: "commit", Data: commitData})
+	if err != nil {
+		t.Fatalf("Write commit: %v", err)
+	}
+
+	dst := NewMemStore(HashSHA256)
+	mapping, err := MigrateToSHA256(src, dst)
+	if err != nil {
+		t.Fatalf("MigrateToSHA256: %v", err)
+	}
+
+	for _, sha := range []string{blobSha, treeSha, commitSha} {
+		if _, ok := mapping[sha]; !ok {
+			t.Errorf("mapping missing entry for %s", sha)
+		}
+	}
+
+	newBlobSha := mapping[blobSha]
+	newTreeSha := mapping[treeSha]
+	newCommitSha := mapping[commitSha]
+
+	if len(newBlobSha) != HashSHA256.Size()*2 {
+		t.Errorf("migrated blob id %q is not a sha256-sized hex digest", newBlobSha)
+	}
+
+	migratedCommit, err := dst.Read(newCommitSha)
+	if err != nil {
+		t.Fatalf("dst.Read(commit): %v", err)
+	}
+	wantCommit := "tree " + newTreeSha + "\n\ninitial commit\n"
+	if string(migratedCommit.Data) != wantCommit {
+		t.Errorf("migrated commit = %q, want %q", migratedCommit.Data, wantCommit)
+	}
+
+	migratedTree, err := dst.Read(newTreeSha)
+	if err != nil {
+		t.Fatalf("dst.Read(tree): %v", err)
+	}
+	entries, err := parseTreeEntries(migratedTree.Data, HashSHA256.Size())
+	if err != nil {
+		t.Fatalf("parseTreeEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].sha != newBlobSha {
+		t.Errorf("migrated tree entries = %+v, want a single entry pointing at %s", entries, newBlobSha)
+	}
+}
+
+// TestMigrateToSHA256StalledReference checks that a tree referencing an
+// id src never produced is reported rather than looping forever.
+func TestMigrateToSHA256StalledReference(t *testing.T) {
+	src := NewMemStore(HashSHA1)
+
+	missingRaw, err := hex.DecodeString("000000000000000000000000000000000000000a")
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	var treeData []byte
+	treeData = append(treeData, []byte("100644 dangling.txt\x00")...)
+	treeData = append(treeData, missingRaw...)
+
+	if _, err := src.Write(GitObject{Kind: "tree", Data: treeData}); err != nil {
+		t.Fatalf("Write tree: %v", err)
+	}
+
+	dst := NewMemStore(HashSHA256)
+	if _, err := MigrateToSHA256(src, dst); err == nil {
+		t.Fatal("MigrateToSHA256 with a dangling reference: want error, got nil")
+	}
+}
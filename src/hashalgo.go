@@ -0,0 +1,109 @@
+package src
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+	"path"
+	"strings"
+)
+
+// HashAlgo - the object hash Git is using for a repository, mirroring
+// the on-disk "extensions.objectFormat" setting. The zero value is
+// "HashSHA1", the format every repository used before sha256 support.
+type HashAlgo int
+
+const (
+	// HashSHA1 - the original, still-default object hash.
+	HashSHA1 HashAlgo = iota
+	// HashSHA256 - "extensions.objectFormat = sha256".
+	HashSHA256
+)
+
+// New - a fresh hash.Hash for this algorithm.
+func (a HashAlgo) New() hash.Hash {
+	switch a {
+	case HashSHA256:
+		return sha256.New()
+	default:
+		return sha1.New()
+	}
+}
+
+// Size - the length, in raw bytes, of a digest produced by this algorithm.
+func (a HashAlgo) Size() int {
+	switch a {
+	case HashSHA256:
+		return sha256.Size
+	default:
+		return sha1.Size
+	}
+}
+
+// String - the "extensions.objectFormat" spelling of this algorithm.
+func (a HashAlgo) String() string {
+	switch a {
+	case HashSHA256:
+		return "sha256"
+	default:
+		return "sha1"
+	}
+}
+
+// ParseHashAlgo - parses an "extensions.objectFormat" value.
+func ParseHashAlgo(s string) (HashAlgo, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "sha1":
+		return HashSHA1, nil
+	case "sha256":
+		return HashSHA256, nil
+	default:
+		return HashSHA1, fmt.Errorf("hashalgo: unknown objectFormat %q", s)
+	}
+}
+
+// RepoHashAlgo - reads "<gitdir>/config" and returns the repository's
+// configured hash algorithm. A repo with no "extensions.objectFormat"
+// setting (or no config file at all) is SHA-1, same as stock Git.
+func RepoHashAlgo(gitdir string) (HashAlgo, error) {
+	f, err := os.Open(path.Join(gitdir, "config"))
+	if os.IsNotExist(err) {
+		return HashSHA1, nil
+	}
+	if err != nil {
+		return HashSHA1, err
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+
+		if section != "extensions" {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if strings.ToLower(strings.TrimSpace(kv[0])) != "objectformat" {
+			continue
+		}
+		return ParseHashAlgo(kv[1])
+	}
+
+	return HashSHA1, scanner.Err()
+}
@@ -0,0 +1,75 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseHashAlgo(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    HashAlgo
+		wantErr bool
+	}{
+		{"", HashSHA1, false},
+		{"sha1", HashSHA1, false},
+		{"  SHA1  ", HashSHA1, false},
+		{"sha256", HashSHA256, false},
+		{"SHA256", HashSHA256, false},
+		{"sha512", HashSHA1, true},
+	}
+
+	for _, c := range cases {
+		got, err := ParseHashAlgo(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseHashAlgo(%q) error = %v, wantErr %v", c.in, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseHashAlgo(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRepoHashAlgoNoConfig(t *testing.T) {
+	algo, err := RepoHashAlgo(t.TempDir())
+	if err != nil {
+		t.Fatalf("RepoHashAlgo: %v", err)
+	}
+	if algo != HashSHA1 {
+		t.Errorf("RepoHashAlgo with no config file = %v, want HashSHA1", algo)
+	}
+}
+
+func TestRepoHashAlgoSHA256(t *testing.T) {
+	gitdir := t.TempDir()
+	config := "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectFormat = sha256\n"
+	if err := os.WriteFile(filepath.Join(gitdir, "config"), []byte(config), 0666); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	algo, err := RepoHashAlgo(gitdir)
+	if err != nil {
+		t.Fatalf("RepoHashAlgo: %v", err)
+	}
+	if algo != HashSHA256 {
+		t.Errorf("RepoHashAlgo = %v, want HashSHA256", algo)
+	}
+}
+
+func TestRepoHashAlgoIgnoresOtherSections(t *testing.T) {
+	gitdir := t.TempDir()
+	config := "[core]\n\tobjectFormat = sha256\n[extensions]\n\tsomeOtherExtension = true\n"
+	if err := os.WriteFile(filepath.Join(gitdir, "config"), []byte(config), 0666); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	algo, err := RepoHashAlgo(gitdir)
+	if err != nil {
+		t.Fatalf("RepoHashAlgo: %v", err)
+	}
+	if algo != HashSHA1 {
+		t.Errorf("RepoHashAlgo = %v, want HashSHA1", algo)
+	}
+}
@@ -3,13 +3,8 @@ package src
 import (
 	"bytes"
 	"compress/zlib"
-	"crypto/sha1"
 	"encoding/hex"
-	"errors"
 	"io/ioutil"
-	"os"
-	"path"
-	"path/filepath"
 	"strconv"
 )
 
@@ -23,108 +18,72 @@ type GitObject struct {
 	Data []byte
 }
 
-// Write - writes compressed object files by calculating hashes as the filename (sha1)
-func (obj GitObject) Write(gitdir string) (string, error) {
-	// GitObject kind, size, and data in []byte
+// ObjectStore - anywhere loose Git objects can be read from and written
+// to, keyed by their object id (a sha1 or, under "HashSHA256", a sha256
+// hex digest - see hashalgo.go). "FileStore" is the original on-disk
+// zlib layout; "MemStore" and the cloud-bucket stores in store_cloud.go
+// are other implementations, so a repository's objects don't have to
+// live in a local working tree.
+type ObjectStore interface {
+	// Read - fetches and decodes the object with the given id.
+	Read(sha string) (GitObject, error)
+	// Write - encodes and persists obj, returning its id.
+	Write(obj GitObject) (string, error)
+	// Has - reports whether an object with the given id is present.
+	Has(sha string) bool
+	// Iter - calls fn once per stored id, stopping at the first error.
+	Iter(fn func(sha string) error) error
+}
+
+// encodeObject - serialises obj the way a loose object is stored on
+// disk, before zlib compression: "<kind> <size>\x00<data>".
+func encodeObject(obj GitObject) []byte {
 	bKind := []byte(obj.Kind)
-	bSize := []byte(strconv.Itoa(len([]byte(obj.Data)) - 1)) // -1 because it didn't match the experimental result
+	bSize := []byte(strconv.Itoa(len(obj.Data)))
 	bData := []byte(obj.Data)
 
-	// Attatching the chunks
 	sl := [][]byte{bKind, []byte(" "), bSize, []byte{0x00}, bData}
-	content := bytes.Join(sl, []byte(""))
-
-	// Computing hash for the content
-	h := sha1.New()
-	h.Write(content)
-	sha := h.Sum(nil)
-
-	// String representation of "sha" (uint8) (encoded in base16)
-	shaStr := hex.EncodeToString(sha)
+	return bytes.Join(sl, []byte(""))
+}
 
-	// Creating directory for with first two values of "shaStr"
-	err := os.MkdirAll(path.Join(gitdir, "objects", shaStr[:2]), 0777)
-	if err != nil {
-		return "", err
-	}
+// decodeObject - reverses "encodeObject", splitting a loose object's
+// uncompressed content back into a "GitObject".
+func decodeObject(content []byte) GitObject {
+	x := bytes.IndexByte(content, byte(' '))  // Index of ' ' (rune) in file data
+	y := bytes.IndexByte(content, byte(0x00)) // Index of 0x00 (null seperator) in file data
 
-	// Absolute Path to the written file
-	nFilePath, err := filepath.Abs(path.Join(gitdir, "objects", shaStr[:2], shaStr[2:]))
-	if err != nil {
-		return "", err
+	return GitObject{
+		Kind: string(content[:x]),
+		Size: string(content[x+1 : y]),
+		Data: content[y+1:],
 	}
+}
 
-	// Creating new file with the content
-	nFile, err := os.Create(nFilePath)
-	if err != nil {
-		return "", err
-	}
-	defer nFile.Close()
+// shaOfContent - the digest of a loose object's already-encoded content,
+// under the given hash algorithm.
+func shaOfContent(content []byte, algo HashAlgo) string {
+	h := algo.New()
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
 
-	// Compressing content (zlib)
+// deflate - zlib-compresses content, the on-disk encoding for loose objects.
+func deflate(content []byte) []byte {
 	var b bytes.Buffer
 	w := zlib.NewWriter(&b)
 	w.Write(content)
 	w.Close()
-
-	// Writing compressed content in "nFile", the newly created file
-	_, err = nFile.Write(b.Bytes())
-	if err != nil {
-		return "", err
-	}
-
-	// return nFilePath, nil
-	return shaStr, nil
+	return b.Bytes()
 }
 
-// ReadObjectFile - Reads the object file compressed data, returns uncompressed content
-func ReadObjectFile(objectpath string) ([]byte, error) {
-	// Reading file data (Compressed)
-	data, err := ioutil.ReadFile(objectpath)
+// inflate - reverses "deflate".
+func inflate(compressed []byte) ([]byte, error) {
+	b := bytes.NewReader(compressed)
+	r, err := zlib.NewReader(b)
 	if err != nil {
 		return nil, err
 	}
+	defer r.Close()
 
-	// Decompressing data (zlib)
-	b := bytes.NewReader(data)
-	raw, err := zlib.NewReader(b) // req - Decompressed Data
-	if err != nil {
-		return nil, err
-	}
-
-	defer raw.Close()
-
-	// Data in []byte
-	bData, err := ioutil.ReadAll(raw)
-	if err != nil {
-		return nil, err
-	}
-
-	return bData, nil
-}
-
-// ReadObject - Reads a object and returns appropriate "GitObject" struct
-func ReadObject(objectpath string) (GitObject, error) {
-	// Check if file exist or not
-	if _, err := os.Stat(objectpath); os.IsNotExist(err) {
-		return GitObject{}, errors.New("Specifies file does not exist")
-	}
-
-	// Reading File Data
-	fData, err := ReadObjectFile(objectpath)
-	if err != nil {
-		return GitObject{}, err
-	}
-
-	// fmt.Printf("content:\n%+s\n", fData)
-
-	x := bytes.IndexByte(fData, byte(' '))  // Index of ' ' (rune) in file data
-	y := bytes.IndexByte(fData, byte(0x00)) // Index of 0x00 (null seperator) in file data
-
-	return GitObject{
-		Kind: string(fData[:x]),
-		Size: string(fData[x+1 : y]),
-		Data: fData[y+1:],
-	}, nil
-
+	return ioutil.ReadAll(r)
 }
@@ -0,0 +1,201 @@
+package src
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// VerifyObject - re-inflates and re-hashes the object stored under sha in
+// store, checks the digest matches sha, and parses its payload according
+// to its Kind (tree entries, commit headers, tag headers). Returns a
+// non-nil error describing the first problem found.
+func VerifyObject(store ObjectStore, sha string, algo HashAlgo) error {
+	obj, err := store.Read(sha)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", sha, err)
+	}
+
+	content := encodeObject(obj)
+	if got := shaOfContent(content, algo); got != sha {
+		return fmt.Errorf("verify %s: content hashes to %s", sha, got)
+	}
+
+	if err := verifyPayload(obj, algo); err != nil {
+		return fmt.Errorf("verify %s: %w", sha, err)
+	}
+
+	return nil
+}
+
+// verifyPayload - validates obj.Data parses as a well-formed payload for
+// obj.Kind.
+func verifyPayload(obj GitObject, algo HashAlgo) error {
+	switch obj.Kind {
+	case "blob":
+		return nil // any byte sequence is a valid blob
+	case "tree":
+		_, err := parseTreeEntries(obj.Data, algo.Size())
+		return err
+	case "commit":
+		return verifyHeaders(obj.Data, []string{"tree"}, []string{"parent"})
+	case "tag":
+		return verifyHeaders(obj.Data, []string{"object", "type", "tag"}, nil)
+	default:
+		return fmt.Errorf("unknown object kind %q", obj.Kind)
+	}
+}
+
+// treeEntry - one decoded "mode SP name NUL <sha>" entry.
+type treeEntry struct {
+	mode string
+	name string
+	sha  string
+}
+
+// parseTreeEntries - decodes every entry of a tree object's payload,
+// each of which is expected to carry a shaSize-byte raw id.
+func parseTreeEntries(data []byte, shaSize int) ([]treeEntry, error) {
+	var entries []treeEntry
+
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp <= 0 {
+			return nil, fmt.Errorf("tree: malformed entry, missing mode separator")
+		}
+
+		nul := bytes.IndexByte(data[sp:], 0x00)
+		if nul < 0 {
+			return nil, fmt.Errorf("tree: malformed entry, missing name terminator")
+		}
+		nul += sp
+
+		if len(data) < nul+1+shaSize {
+			return nil, fmt.Errorf("tree: truncated entry")
+		}
+
+		entries = append(entries, treeEntry{
+			mode: string(data[:sp]),
+			name: string(data[sp+1 : nul]),
+			sha:  hex.EncodeToString(data[nul+1 : nul+1+shaSize]),
+		})
+
+		data = data[nul+1+shaSize:]
+	}
+
+	return entries, nil
+}
+
+// verifyHeaders - checks that every entry of required appears as a
+// "<key> ...\n" header line before the first blank line, in any order.
+func verifyHeaders(data []byte, required []string, optional []string) error {
+	lines := bytes.Split(data, []byte("\n"))
+
+	seen := make(map[string]bool, len(required))
+	for _, line := range lines {
+		if len(line) == 0 {
+			break
+		}
+		sp := bytes.IndexByte(line, ' ')
+		if sp <= 0 {
+			return fmt.Errorf("malformed header line %q", line)
+		}
+		seen[string(line[:sp])] = true
+	}
+
+	for _, key := range required {
+		if !seen[key] {
+			return fmt.Errorf("missing %q header", key)
+		}
+	}
+	_ = optional // documents which headers are allowed but not mandatory
+
+	return nil
+}
+
+// referencedIDs - every object id obj.Data points at, used by "Fsck" to
+// find dangling references.
+func referencedIDs(obj GitObject, algo HashAlgo) ([]string, error) {
+	switch obj.Kind {
+	case "tree":
+		entries, err := parseTreeEntries(obj.Data, algo.Size())
+		if err != nil {
+			return nil, err
+		}
+		refs := make([]string, len(entries))
+		for i, e := range entries {
+			refs[i] = e.sha
+		}
+		return refs, nil
+	case "commit":
+		return headerRefs(obj.Data, []string{"tree", "parent"}), nil
+	case "tag":
+		return headerRefs(obj.Data, []string{"object"}), nil
+	default:
+		return nil, nil
+	}
+}
+
+// headerRefs - the ids following any of prefixes at the start of a
+// header line, up to the first blank line.
+func headerRefs(data []byte, prefixes []string) []string {
+	var refs []string
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			break
+		}
+		for _, prefix := range prefixes {
+			if bytes.HasPrefix(line, []byte(prefix+" ")) {
+				refs = append(refs, string(line[len(prefix)+1:]))
+			}
+		}
+	}
+
+	return refs
+}
+
+// FsckIssue - one problem found by "Fsck": either an object that failed
+// "VerifyObject", or a reference to an object the store doesn't have.
+type FsckIssue struct {
+	Sha    string
+	Reason string
+}
+
+func (i FsckIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Sha, i.Reason)
+}
+
+// Fsck - walks every object in store, verifying each one and flagging
+// dangling references, in the spirit of "git fsck".
+func Fsck(store ObjectStore, algo HashAlgo) ([]FsckIssue, error) {
+	var issues []FsckIssue
+
+	err := store.Iter(func(sha string) error {
+		if err := VerifyObject(store, sha, algo); err != nil {
+			issues = append(issues, FsckIssue{Sha: sha, Reason: err.Error()})
+			return nil
+		}
+
+		obj, err := store.Read(sha)
+		if err != nil {
+			return err
+		}
+
+		refs, err := referencedIDs(obj, algo)
+		if err != nil {
+			issues = append(issues, FsckIssue{Sha: sha, Reason: err.Error()})
+			return nil
+		}
+
+		for _, ref := range refs {
+			if !store.Has(ref) {
+				issues = append(issues, FsckIssue{Sha: sha, Reason: "dangling reference to " + ref})
+			}
+		}
+
+		return nil
+	})
+
+	return issues, err
+}
@@ -0,0 +1,73 @@
+package src
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestVerifyObject(t *testing.T) {
+	store := NewMemStore(HashSHA1)
+	obj := GitObject{Kind: "blob", Data: []byte("verify me")}
+
+	sha, err := store.Write(obj)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := VerifyObject(store, sha, HashSHA1); err != nil {
+		t.Fatalf("VerifyObject on an untouched object: %v", err)
+	}
+
+	// Corrupt the stored object in place; its content no longer hashes
+	// back to the id it's keyed under.
+	store.objs[sha] = GitObject{Kind: "blob", Data: []byte("tampered")}
+
+	if err := VerifyObject(store, sha, HashSHA1); err == nil {
+		t.Fatal("VerifyObject on a tampered object: want error, got nil")
+	}
+}
+
+func TestFsckDanglingReference(t *testing.T) {
+	store := NewMemStore(HashSHA1)
+
+	blobSha, err := store.Write(GitObject{Kind: "blob", Data: []byte("tracked file")})
+	if err != nil {
+		t.Fatalf("Write blob: %v", err)
+	}
+
+	missingSha := strings.Repeat("0", 40)
+	missingRaw, err := hex.DecodeString(missingSha)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	blobRaw, err := hex.DecodeString(blobSha)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+
+	var tree []byte
+	tree = append(tree, []byte("100644 present.txt\x00")...)
+	tree = append(tree, blobRaw...)
+	tree = append(tree, []byte("100644 missing.txt\x00")...)
+	tree = append(tree, missingRaw...)
+
+	if _, err := store.Write(GitObject{Kind: "tree", Data: tree}); err != nil {
+		t.Fatalf("Write tree: %v", err)
+	}
+
+	issues, err := Fsck(store, HashSHA1)
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+
+	found := false
+	for _, issue := range issues {
+		if strings.Contains(issue.Reason, missingSha) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Fsck issues %v do not flag dangling reference to %s", issues, missingSha)
+	}
+}
@@ -0,0 +1,189 @@
+// Package objfile implements streaming reads and writes of the loose
+// object file format ("<kind> <size>\x00<data>", zlib-compressed),
+// without ever holding a whole object in memory - the format the loose
+// object stores of package src use, but usable for blobs far bigger than
+// the generic GitObject.Data []byte allows comfortably.
+package objfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+)
+
+// Writer - an "io.WriteCloser" that zlib-compresses and hashes an
+// object's payload as it is written, buffering only to a temp file, then
+// atomically renames that temp file into "<gitdir>/objects/xx/yyyy..."
+// on Close.
+type Writer struct {
+	gitdir string
+	tmp    *os.File
+	zw     *zlib.Writer
+	hash   hash.Hash
+	size   int64
+
+	written int64
+	sha     string
+	closed  bool
+}
+
+// NewWriter - a "Writer" that will store a "kind" object of the given
+// (uncompressed, header-exclusive) size under gitdir once closed. newHash
+// picks the digest algorithm (e.g. "sha1.New" or "sha256.New").
+func NewWriter(gitdir string, kind string, size int64, newHash func() hash.Hash) (*Writer, error) {
+	objectsDir := path.Join(gitdir, "objects")
+	if err := os.MkdirAll(objectsDir, 0777); err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(objectsDir, "incoming-")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		gitdir: gitdir,
+		tmp:    tmp,
+		hash:   newHash(),
+		size:   size,
+	}
+	w.zw = zlib.NewWriter(tmp)
+
+	header := []byte(kind + " " + strconv.FormatInt(size, 10) + "\x00")
+	if _, err := w.hash.Write(header); err != nil {
+		return nil, err
+	}
+	if _, err := w.zw.Write(header); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write - hashes and compresses p, writing it to the temp file backing w.
+func (w *Writer) Write(p []byte) (int, error) {
+	if _, err := w.hash.Write(p); err != nil {
+		return 0, err
+	}
+	n, err := w.zw.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Close - flushes the compressed stream, verifies the number of bytes
+// written matched the size passed to "NewWriter", and atomically renames
+// the temp file into its final "objects/xx/yyyy..." location. The
+// resulting id is available from "Sha" afterwards.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.written != w.size {
+		w.tmp.Close()
+		os.Remove(w.tmp.Name())
+		return fmt.Errorf("objfile: wrote %d bytes, expected %d", w.written, w.size)
+	}
+
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+	if err := w.tmp.Close(); err != nil {
+		return err
+	}
+
+	w.sha = hex.EncodeToString(w.hash.Sum(nil))
+
+	dir := path.Join(w.gitdir, "objects", w.sha[:2])
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	return os.Rename(w.tmp.Name(), path.Join(dir, w.sha[2:]))
+}
+
+// Sha - the id of the object written, valid after "Close" returns nil.
+func (w *Writer) Sha() string {
+	return w.sha
+}
+
+// Reader - streams the inflated payload of a loose object file without
+// reading it fully into memory.
+type Reader struct {
+	f    *os.File
+	zr   io.ReadCloser
+	br   *bufio.Reader
+	kind string
+	size int64
+}
+
+// NewReader - opens the loose object file at objectpath and parses its
+// "<kind> <size>\x00" header, positioning the returned Reader at the
+// start of the payload.
+func NewReader(objectpath string) (*Reader, error) {
+	f, err := os.Open(objectpath)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(zr)
+	header, err := br.ReadString(0x00)
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return nil, err
+	}
+	header = header[:len(header)-1] // drop the trailing NUL
+
+	sp := bytes.IndexByte([]byte(header), ' ')
+	if sp < 0 {
+		zr.Close()
+		f.Close()
+		return nil, errors.New("objfile: malformed header")
+	}
+
+	size, err := strconv.ParseInt(header[sp+1:], 10, 64)
+	if err != nil {
+		zr.Close()
+		f.Close()
+		return nil, fmt.Errorf("objfile: malformed size: %w", err)
+	}
+
+	return &Reader{f: f, zr: zr, br: br, kind: header[:sp], size: size}, nil
+}
+
+// Header - the object's kind and the uncompressed size of its payload.
+func (r *Reader) Header() (kind string, size int64) {
+	return r.kind, r.size
+}
+
+// Read - streams the inflated payload, following "Header".
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.br.Read(p)
+}
+
+// Close - releases the underlying zlib stream and file handle.
+func (r *Reader) Close() error {
+	zerr := r.zr.Close()
+	ferr := r.f.Close()
+	if zerr != nil {
+		return zerr
+	}
+	return ferr
+}
@@ -0,0 +1,65 @@
+package objfile
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	gitdir := t.TempDir()
+	payload := []byte("streamed blob contents, not held fully in memory")
+
+	w, err := NewWriter(gitdir, "blob", int64(len(payload)), sha1.New)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sha := w.Sha()
+	if len(sha) != 40 {
+		t.Fatalf("Sha() = %q, want a 40-char sha1 hex digest", sha)
+	}
+
+	objectpath := gitdir + "/objects/" + sha[:2] + "/" + sha[2:]
+	r, err := NewReader(objectpath)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	defer r.Close()
+
+	kind, size := r.Header()
+	if kind != "blob" || size != int64(len(payload)) {
+		t.Errorf("Header() = (%q, %d), want (%q, %d)", kind, size, "blob", len(payload))
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("round-tripped payload = %q, want %q", got, payload)
+	}
+}
+
+func TestWriterCloseSizeMismatch(t *testing.T) {
+	gitdir := t.TempDir()
+
+	w, err := NewWriter(gitdir, "blob", 100, sha1.New)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("only a few bytes")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := w.Close(); err == nil {
+		t.Fatal("Close with fewer bytes than declared: want error, got nil")
+	}
+}
@@ -0,0 +1,513 @@
+package src
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// packObjType - object type tags used inside a packfile's per-object header,
+// as defined by the Git pack format.
+const (
+	packObjCommit   = 1
+	packObjTree     = 2
+	packObjBlob     = 3
+	packObjTag      = 4
+	packObjOfsDelta = 6
+	packObjRefDelta = 7
+)
+
+var packKindByType = map[int]string{
+	packObjCommit: "commit",
+	packObjTree:   "tree",
+	packObjBlob:   "blob",
+	packObjTag:    "tag",
+}
+
+// ObjectIterator - a pull-style source of "GitObject"s, used by
+// "WritePackfile" so callers don't have to materialise every object up
+// front. "Next" returns "io.EOF" once exhausted.
+type ObjectIterator interface {
+	Next() (GitObject, error)
+}
+
+// sliceObjectIterator - the simplest "ObjectIterator", backed by a slice.
+type sliceObjectIterator struct {
+	objs []GitObject
+	pos  int
+}
+
+// NewSliceObjectIterator - wraps a slice of "GitObject" as an "ObjectIterator".
+func NewSliceObjectIterator(objs []GitObject) ObjectIterator {
+	return &sliceObjectIterator{objs: objs}
+}
+
+func (it *sliceObjectIterator) Next() (GitObject, error) {
+	if it.pos >= len(it.objs) {
+		return GitObject{}, io.EOF
+	}
+	obj := it.objs[it.pos]
+	it.pos++
+	return obj, nil
+}
+
+// ReadPackfile - reads a ".pack" file, reconstructs every object it
+// contains (inflating base objects and applying OBJ_OFS_DELTA /
+// OBJ_REF_DELTA instructions against a resolved base), and writes each
+// one into store. OBJ_REF_DELTA base ids are algo.Size() bytes wide.
+// Returns the id of every object written, in pack order.
+func ReadPackfile(packpath string, store ObjectStore, algo HashAlgo) ([]string, error) {
+	raw, err := ioutil.ReadFile(packpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < 12 || string(raw[:4]) != "PACK" {
+		return nil, errors.New("packfile: missing PACK magic")
+	}
+
+	version := binary.BigEndian.Uint32(raw[4:8])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("packfile: unsupported version %d", version)
+	}
+	count := binary.BigEndian.Uint32(raw[8:12])
+
+	// Resolved content (without the "kind size\x00" header), keyed by
+	// both the object's starting offset in the pack and, once known,
+	// its id - REF_DELTA bases may be objects already written out in
+	// an earlier iteration of this loop, or already present in store.
+	type resolved struct {
+		kind string
+		data []byte
+	}
+	byOffset := make(map[int64]resolved, count)
+	bySha := make(map[string]resolved, count)
+
+	shas := make([]string, 0, count)
+	pos := int64(12)
+
+	for i := uint32(0); i < count; i++ {
+		start := pos
+
+		objType, size, n := readPackObjHeader(raw[pos:])
+		pos += int64(n)
+
+		var kind string
+		var data []byte
+
+		switch objType {
+		case packObjCommit, packObjTree, packObjBlob, packObjTag:
+			inflated, read, err := zlibInflate(raw[pos:])
+			if err != nil {
+				return nil, err
+			}
+			pos += int64(read)
+			kind = packKindByType[objType]
+			data = inflated
+
+		case packObjOfsDelta:
+			back, n := readOfsDeltaOffset(raw[pos:])
+			pos += int64(n)
+			deltaBytes, read, err := zlibInflate(raw[pos:])
+			if err != nil {
+				return nil, err
+			}
+			pos += int64(read)
+
+			base, ok := byOffset[start-back]
+			if !ok {
+				return nil, fmt.Errorf("packfile: ofs-delta at %d has no base at %d", start, start-back)
+			}
+			data, err = applyDelta(base.data, deltaBytes)
+			if err != nil {
+				return nil, err
+			}
+			kind = base.kind
+
+		case packObjRefDelta:
+			idSize := int64(algo.Size())
+			baseSha := hex.EncodeToString(raw[pos : pos+idSize])
+			pos += idSize
+			deltaBytes, read, err := zlibInflate(raw[pos:])
+			if err != nil {
+				return nil, err
+			}
+			pos += int64(read)
+
+			base, ok := bySha[baseSha]
+			if !ok {
+				base, ok = resolveLooseBase(store, baseSha)
+			}
+			if !ok {
+				return nil, fmt.Errorf("packfile: ref-delta base %s not found", baseSha)
+			}
+			data, err = applyDelta(base.data, deltaBytes)
+			if err != nil {
+				return nil, err
+			}
+			kind = base.kind
+
+		default:
+			return nil, fmt.Errorf("packfile: unknown object type %d", objType)
+		}
+
+		_ = size // size is only advisory; len(data) is authoritative post-inflate/delta
+
+		sha, err := store.Write(GitObject{Kind: kind, Data: data})
+		if err != nil {
+			return nil, err
+		}
+
+		byOffset[start] = resolved{kind: kind, data: data}
+		bySha[sha] = resolved{kind: kind, data: data}
+		shas = append(shas, sha)
+	}
+
+	return shas, nil
+}
+
+// resolveLooseBase - falls back to store when a REF_DELTA base was not
+// itself packed earlier in this same packfile.
+func resolveLooseBase(store ObjectStore, sha string) (struct {
+	kind string
+	data []byte
+}, bool) {
+	obj, err := store.Read(sha)
+	if err != nil {
+		return struct {
+			kind string
+			data []byte
+		}{}, false
+	}
+	return struct {
+		kind string
+		data []byte
+	}{kind: obj.Kind, data: obj.Data}, true
+}
+
+// readPackObjHeader - decodes the varint-encoded "(type, size)" pair that
+// precedes every object in a packfile, returning the number of bytes it
+// occupied. "size" is the uncompressed size of the base/delta payload.
+func readPackObjHeader(b []byte) (objType int, size uint64, n int) {
+	c := b[0]
+	objType = int((c >> 4) & 0x07)
+	size = uint64(c & 0x0f)
+	shift := uint(4)
+	n = 1
+	for c&0x80 != 0 {
+		c = b[n]
+		size |= uint64(c&0x7f) << shift
+		shift += 7
+		n++
+	}
+	return objType, size, n
+}
+
+// readOfsDeltaOffset - decodes the "OBJ_OFS_DELTA" base offset, which uses
+// Git's own (non-LEB128) big-endian varint with a running bias.
+func readOfsDeltaOffset(b []byte) (offset int64, n int) {
+	c := b[0]
+	n = 1
+	offset = int64(c & 0x7f)
+	for c&0x80 != 0 {
+		c = b[n]
+		n++
+		offset++
+		offset = (offset << 7) | int64(c&0x7f)
+	}
+	return offset, n
+}
+
+// zlibInflate - inflates a zlib stream starting at b[0], returning the
+// decompressed bytes and the number of compressed bytes consumed.
+func zlibInflate(b []byte) ([]byte, int, error) {
+	br := bytes.NewReader(b)
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer zr.Close()
+
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// bytes.Reader tracks how much of "b" the zlib reader consumed.
+	consumed := len(b) - br.Len()
+	return data, consumed, nil
+}
+
+// applyDelta - reconstructs a target object by replaying the copy/insert
+// instructions of a Git delta stream against "base".
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	srcSize, n := readDeltaVarint(delta)
+	delta = delta[n:]
+	if uint64(len(base)) != srcSize {
+		return nil, fmt.Errorf("delta: base size mismatch, want %d got %d", srcSize, len(base))
+	}
+
+	targetSize, n := readDeltaVarint(delta)
+	delta = delta[n:]
+
+	out := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+
+		if cmd&0x80 != 0 {
+			// Copy opcode: bits 0-3 select which offset bytes follow,
+			// bits 4-6 select which size bytes follow.
+			var offset, size int
+			if cmd&0x01 != 0 {
+				offset |= int(delta[0])
+				delta = delta[1:]
+			}
+			if cmd&0x02 != 0 {
+				offset |= int(delta[0]) << 8
+				delta = delta[1:]
+			}
+			if cmd&0x04 != 0 {
+				offset |= int(delta[0]) << 16
+				delta = delta[1:]
+			}
+			if cmd&0x08 != 0 {
+				offset |= int(delta[0]) << 24
+				delta = delta[1:]
+			}
+			if cmd&0x10 != 0 {
+				size |= int(delta[0])
+				delta = delta[1:]
+			}
+			if cmd&0x20 != 0 {
+				size |= int(delta[0]) << 8
+				delta = delta[1:]
+			}
+			if cmd&0x40 != 0 {
+				size |= int(delta[0]) << 16
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errors.New("delta: copy instruction out of range")
+			}
+			out = append(out, base[offset:offset+size]...)
+
+		} else if cmd != 0 {
+			// Insert opcode: bits 0-6 give the number of literal bytes
+			// that follow (N=0 is reserved and never produced by Git).
+			n := int(cmd & 0x7f)
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+
+		} else {
+			return nil, errors.New("delta: reserved opcode 0x00")
+		}
+	}
+
+	if uint64(len(out)) != targetSize {
+		return nil, fmt.Errorf("delta: target size mismatch, want %d got %d", targetSize, len(out))
+	}
+	return out, nil
+}
+
+// readDeltaVarint - decodes the plain 7-bit-per-byte little-endian varint
+// used for the source/target size fields at the start of a delta stream.
+func readDeltaVarint(b []byte) (uint64, int) {
+	var v uint64
+	var shift uint
+	var n int
+	for {
+		c := b[n]
+		v |= uint64(c&0x7f) << shift
+		n++
+		if c&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	return v, n
+}
+
+// WritePackfile - writes every object yielded by "it" into a new packfile
+// at "packpath", along with a matching ".idx" (v2) sibling, hashed under
+// algo, and returns the ".idx" path. Objects are written undeltified; see
+// "Repack" for a writer that also emits delta-compressed entries.
+func WritePackfile(packpath string, it ObjectIterator, algo HashAlgo) (idxpath string, err error) {
+	var body bytes.Buffer
+	var entries []packIndexEntry
+	count := uint32(0)
+
+	for {
+		obj, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		offset := int64(body.Len())
+
+		objType, ok := packTypeByKind(obj.Kind)
+		if !ok {
+			return "", fmt.Errorf("packfile: unwritable object kind %q", obj.Kind)
+		}
+
+		header := encodePackObjHeader(objType, uint64(len(obj.Data)))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(obj.Data)
+		zw.Close()
+
+		crc := crc32Of(append(append([]byte{}, header...), compressed.Bytes()...))
+
+		body.Write(header)
+		body.Write(compressed.Bytes())
+
+		sha := shaOfObject(obj, algo)
+		entries = append(entries, packIndexEntry{sha: sha, offset: offset, crc32: crc})
+		count++
+	}
+
+	var out bytes.Buffer
+	out.WriteString("PACK")
+	binary.Write(&out, binary.BigEndian, uint32(2))
+	binary.Write(&out, binary.BigEndian, count)
+	out.Write(body.Bytes())
+
+	h := algo.New()
+	h.Write(out.Bytes())
+	trailer := h.Sum(nil)
+	out.Write(trailer)
+
+	if err := ioutil.WriteFile(packpath, out.Bytes(), 0666); err != nil {
+		return "", err
+	}
+
+	idxpath = packpath[:len(packpath)-len(".pack")] + ".idx"
+	if err := writePackIndex(idxpath, entries, trailer, algo); err != nil {
+		return "", err
+	}
+	return idxpath, nil
+}
+
+// packIndexEntry - one object's id/offset/crc32, prior to being sorted
+// and split across the three parallel tables of a pack index.
+type packIndexEntry struct {
+	sha    string
+	offset int64
+	crc32  uint32
+}
+
+// writePackIndex - emits a Git pack index v2: a 256-entry fanout table,
+// the sorted object id list, a parallel CRC32 table, a parallel (4-byte)
+// offset table, and the packfile + index trailer checksums, all hashed
+// under algo.
+//
+// Note: unlike stock Git this does not emit the 8-byte large-offset
+// table, so it cannot address packs bigger than 2GiB.
+func writePackIndex(idxpath string, entries []packIndexEntry, packChecksum []byte, algo HashAlgo) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].sha < entries[j].sha })
+
+	var out bytes.Buffer
+	out.Write([]byte{0xff, 0x74, 0x4f, 0x63}) // "\377tOc"
+	binary.Write(&out, binary.BigEndian, uint32(2))
+
+	var fanout [256]uint32
+	for _, e := range entries {
+		b, _ := hex.DecodeString(e.sha[:2])
+		fanout[b[0]]++
+	}
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+	for _, f := range fanout {
+		binary.Write(&out, binary.BigEndian, f)
+	}
+
+	for _, e := range entries {
+		shaBytes, err := hex.DecodeString(e.sha)
+		if err != nil {
+			return err
+		}
+		out.Write(shaBytes)
+	}
+	for _, e := range entries {
+		binary.Write(&out, binary.BigEndian, e.crc32)
+	}
+	for _, e := range entries {
+		binary.Write(&out, binary.BigEndian, uint32(e.offset))
+	}
+
+	out.Write(packChecksum)
+
+	h := algo.New()
+	h.Write(out.Bytes())
+	out.Write(h.Sum(nil))
+
+	return ioutil.WriteFile(idxpath, out.Bytes(), 0666)
+}
+
+func packTypeByKind(kind string) (int, bool) {
+	switch kind {
+	case "commit":
+		return packObjCommit, true
+	case "tree":
+		return packObjTree, true
+	case "blob":
+		return packObjBlob, true
+	case "tag":
+		return packObjTag, true
+	default:
+		return 0, false
+	}
+}
+
+// encodePackObjHeader - encodes the varint "(type, size)" header written
+// ahead of every object in a packfile.
+func encodePackObjHeader(objType int, size uint64) []byte {
+	first := byte(objType<<4) | byte(size&0x0f)
+	size >>= 4
+	if size == 0 {
+		return []byte{first}
+	}
+	first |= 0x80
+
+	out := []byte{first}
+	for size > 0 {
+		b := byte(size & 0x7f)
+		size >>= 7
+		if size > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// shaOfObject - computes the loose-object id for "obj" under algo
+// without writing it to disk, i.e. algo("<kind> <len>\x00<data>").
+func shaOfObject(obj GitObject, algo HashAlgo) string {
+	header := []byte(obj.Kind + " " + fmt.Sprint(len(obj.Data)) + "\x00")
+	h := algo.New()
+	h.Write(header)
+	h.Write(obj.Data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// crc32Of - the CRC32 (IEEE) of a byte slice, as stored per-entry in a
+// pack index.
+func crc32Of(b []byte) uint32 {
+	return crc32.ChecksumIEEE(b)
+}
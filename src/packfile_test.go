@@ -0,0 +1,106 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReadPackfileRoundTrip(t *testing.T) {
+	objs := []GitObject{
+		{Kind: "blob", Data: []byte("hello, world\n")},
+		{Kind: "blob", Data: []byte("a second blob with different content")},
+		{Kind: "tree", Data: []byte{}},
+	}
+
+	packpath := filepath.Join(t.TempDir(), "test.pack")
+	if _, err := WritePackfile(packpath, NewSliceObjectIterator(objs), HashSHA1); err != nil {
+		t.Fatalf("WritePackfile: %v", err)
+	}
+
+	store := NewMemStore(HashSHA1)
+	shas, err := ReadPackfile(packpath, store, HashSHA1)
+	if err != nil {
+		t.Fatalf("ReadPackfile: %v", err)
+	}
+	if len(shas) != len(objs) {
+		t.Fatalf("got %d object ids, want %d", len(shas), len(objs))
+	}
+
+	for i, obj := range objs {
+		wantSha := shaOfObject(obj, HashSHA1)
+		if shas[i] != wantSha {
+			t.Errorf("object %d: got id %s, want %s", i, shas[i], wantSha)
+		}
+
+		got, err := store.Read(wantSha)
+		if err != nil {
+			t.Fatalf("store.Read(%s): %v", wantSha, err)
+		}
+		if got.Kind != obj.Kind || !bytes.Equal(got.Data, obj.Data) {
+			t.Errorf("object %d: got %+v, want %+v", i, got, obj)
+		}
+	}
+}
+
+// TestReadPackfileRefDeltaSHA256 hand-builds a packfile with an
+// OBJ_REF_DELTA entry whose 32-byte SHA-256 base id must be read in
+// full - a 20-byte (SHA-1-sized) read would consume only part of the id
+// and misalign the zlib stream that follows it.
+func TestReadPackfileRefDeltaSHA256(t *testing.T) {
+	base := GitObject{Kind: "blob", Data: []byte("base content for a ref-delta test")}
+	target := GitObject{Kind: "blob", Data: []byte("base content for a ref-delta test, plus more appended")}
+	baseSha := shaOfObject(base, HashSHA256)
+
+	delta := deltaEncode(base.Data, target.Data, 4)
+
+	var body bytes.Buffer
+
+	baseHeader := encodePackObjHeader(packObjBlob, uint64(len(base.Data)))
+	body.Write(baseHeader)
+	body.Write(deflate(base.Data))
+
+	deltaHeader := encodePackObjHeader(packObjRefDelta, uint64(len(delta)))
+	body.Write(deltaHeader)
+	baseShaRaw, err := hex.DecodeString(baseSha)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	body.Write(baseShaRaw)
+	body.Write(deflate(delta))
+
+	var out bytes.Buffer
+	out.WriteString("PACK")
+	binary.Write(&out, binary.BigEndian, uint32(2))
+	binary.Write(&out, binary.BigEndian, uint32(2))
+	out.Write(body.Bytes())
+
+	h := HashSHA256.New()
+	h.Write(out.Bytes())
+	out.Write(h.Sum(nil))
+
+	packpath := filepath.Join(t.TempDir(), "refdelta.pack")
+	if err := ioutil.WriteFile(packpath, out.Bytes(), 0666); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	store := NewMemStore(HashSHA256)
+	shas, err := ReadPackfile(packpath, store, HashSHA256)
+	if err != nil {
+		t.Fatalf("ReadPackfile: %v", err)
+	}
+	if len(shas) != 2 {
+		t.Fatalf("got %d object ids, want 2", len(shas))
+	}
+
+	got, err := store.Read(shas[1])
+	if err != nil {
+		t.Fatalf("store.Read(%s): %v", shas[1], err)
+	}
+	if !bytes.Equal(got.Data, target.Data) {
+		t.Fatalf("ref-delta reconstruction mismatch: got %q want %q", got.Data, target.Data)
+	}
+}
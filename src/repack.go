@@ -0,0 +1,164 @@
+package src
+
+import (
+	"hash/adler32"
+	"math/bits"
+	"os"
+	"path"
+	"sort"
+)
+
+// RepackOptions - tuning knobs for "Repack".
+type RepackOptions struct {
+	// FingerprintWindow - the prefix length hashed when clustering
+	// objects by similarity. Defaults to 64 when zero.
+	FingerprintWindow int
+	// MinMatch - the shortest run of common bytes "deltaEncode" will
+	// emit as a copy instruction rather than leaving it as literal
+	// insert bytes. Defaults to 16 when zero.
+	MinMatch int
+}
+
+// Repack - reads every loose object out of gitdir's "FileStore", groups
+// blobs (and, separately, trees/commits/tags) by similarity, deltifies
+// each group against a chosen base of the same kind, and writes the
+// result as a single packfile + ".idx" under
+// "<gitdir>/objects/pack/". Loose files that made it into the pack are
+// then pruned.
+func Repack(gitdir string, opts RepackOptions) (packpath string, idxpath string, err error) {
+	if opts.FingerprintWindow == 0 {
+		opts.FingerprintWindow = 64
+	}
+	if opts.MinMatch == 0 {
+		opts.MinMatch = 16
+	}
+
+	store, err := NewFileStore(gitdir)
+	if err != nil {
+		return "", "", err
+	}
+
+	var shas []string
+	if err := store.Iter(func(sha string) error {
+		shas = append(shas, sha)
+		return nil
+	}); err != nil {
+		return "", "", err
+	}
+
+	objs := make([]GitObject, 0, len(shas))
+	for _, sha := range shas {
+		obj, err := store.Read(sha)
+		if err != nil {
+			return "", "", err
+		}
+		objs = append(objs, obj)
+	}
+
+	entries := planPackEntries(objs, opts)
+
+	packDir := path.Join(gitdir, "objects", "pack")
+	if err := os.MkdirAll(packDir, 0777); err != nil {
+		return "", "", err
+	}
+
+	packpath, idxpath, err = writePlannedPack(packDir, entries, store.Algo)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, sha := range shas {
+		os.Remove(store.path(sha))
+	}
+
+	return packpath, idxpath, nil
+}
+
+// plannedEntry - one object queued for packing, either stored whole or
+// as a delta against another entry in the same plan (identified by
+// baseIdx, resolved to a pack offset once the pack is actually written).
+type plannedEntry struct {
+	obj     GitObject
+	isDelta bool
+	baseIdx int // index into the plan's entries slice; only set if isDelta
+	delta   []byte
+}
+
+// planPackEntries - groups objs by kind and similarity fingerprint, and
+// picks, per group, which entries become deltas against the group's
+// largest member.
+func planPackEntries(objs []GitObject, opts RepackOptions) []plannedEntry {
+	byKind := make(map[string][]int, 4)
+	for i, obj := range objs {
+		byKind[obj.Kind] = append(byKind[obj.Kind], i)
+	}
+
+	entries := make([]plannedEntry, len(objs))
+	for i, obj := range objs {
+		entries[i] = plannedEntry{obj: obj}
+	}
+
+	for _, idxs := range byKind {
+		for _, group := range clusterBySimilarity(objs, idxs, opts.FingerprintWindow) {
+			deltifyGroup(objs, group, entries, opts.MinMatch)
+		}
+	}
+
+	return entries
+}
+
+// clusterBySimilarity - buckets the given object indices by a
+// (log2-size, prefix-fingerprint) key, the same two signals Git's own
+// "--window" heuristics lean on to find plausible delta candidates
+// cheaply, without ever diffing every pair of objects.
+func clusterBySimilarity(objs []GitObject, idxs []int, window int) [][]int {
+	groups := make(map[uint64][]int)
+	for _, i := range idxs {
+		key := similarityKey(objs[i].Data, window)
+		groups[key] = append(groups[key], i)
+	}
+
+	out := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	return out
+}
+
+// similarityKey - a (size-bucket, fingerprint) pair packed into a single
+// uint64: the size bucket is log2(size) so near-duplicate edits of
+// roughly the same length land together, and the fingerprint is an
+// Adler-32 of the object's first "window" bytes.
+func similarityKey(data []byte, window int) uint64 {
+	sizeBucket := uint64(bits.Len(uint(len(data))))
+
+	if window > len(data) {
+		window = len(data)
+	}
+	fp := uint64(adler32.Checksum(data[:window]))
+
+	return sizeBucket<<32 | fp
+}
+
+// deltifyGroup - within one similarity cluster, picks the largest object
+// as the base (stored whole) and deltifies every other member against it
+// when doing so is actually smaller than storing it whole.
+func deltifyGroup(objs []GitObject, group []int, entries []plannedEntry, minMatch int) {
+	if len(group) < 2 {
+		return
+	}
+
+	sort.Slice(group, func(a, b int) bool {
+		return len(objs[group[a]].Data) > len(objs[group[b]].Data)
+	})
+	baseIdx := group[0]
+	base := objs[baseIdx]
+
+	for _, i := range group[1:] {
+		delta := deltaEncode(base.Data, objs[i].Data, minMatch)
+		if len(delta) >= len(objs[i].Data) {
+			continue // not worth it, leave this entry stored whole
+		}
+		entries[i] = plannedEntry{obj: objs[i], isDelta: true, baseIdx: baseIdx, delta: delta}
+	}
+}
@@ -0,0 +1,98 @@
+package src
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// writePlannedPack - serialises a "planPackEntries" plan into a packfile
+// and its sibling ".idx" under packDir, named after the pack's trailing
+// checksum (hashed under algo) like Git's own repack output. Whole
+// objects are written before any delta that targets them, so every
+// OBJ_OFS_DELTA's backward offset always resolves.
+func writePlannedPack(packDir string, entries []plannedEntry, algo HashAlgo) (packpath string, idxpath string, err error) {
+	var body bytes.Buffer
+	offsets := make([]int64, len(entries))
+	var idxEntries []packIndexEntry
+
+	write := func(i int) error {
+		e := entries[i]
+		offset := int64(body.Len())
+		offsets[i] = offset
+
+		var header, payload []byte
+		if e.isDelta {
+			header = encodePackObjHeader(packObjOfsDelta, uint64(len(e.delta)))
+			header = append(header, encodeOfsDeltaOffset(offset-offsets[e.baseIdx])...)
+			payload = e.delta
+		} else {
+			objType, ok := packTypeByKind(e.obj.Kind)
+			if !ok {
+				return fmt.Errorf("repack: unpackable object kind %q", e.obj.Kind)
+			}
+			header = encodePackObjHeader(objType, uint64(len(e.obj.Data)))
+			payload = e.obj.Data
+		}
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(payload)
+		zw.Close()
+
+		crc := crc32Of(append(append([]byte{}, header...), compressed.Bytes()...))
+		body.Write(header)
+		body.Write(compressed.Bytes())
+
+		idxEntries = append(idxEntries, packIndexEntry{
+			sha:    shaOfObject(e.obj, algo),
+			offset: offset,
+			crc32:  crc,
+		})
+		return nil
+	}
+
+	// Whole objects first, so every delta's base offset precedes it.
+	for i, e := range entries {
+		if !e.isDelta {
+			if err := write(i); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	for i, e := range entries {
+		if e.isDelta {
+			if err := write(i); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("PACK")
+	binary.Write(&out, binary.BigEndian, uint32(2))
+	binary.Write(&out, binary.BigEndian, uint32(len(entries)))
+	out.Write(body.Bytes())
+
+	h := algo.New()
+	h.Write(out.Bytes())
+	trailer := h.Sum(nil)
+	out.Write(trailer)
+
+	name := "pack-" + hex.EncodeToString(trailer)
+	packpath = path.Join(packDir, name+".pack")
+	if err := ioutil.WriteFile(packpath, out.Bytes(), 0666); err != nil {
+		return "", "", err
+	}
+
+	idxpath = path.Join(packDir, name+".idx")
+	if err := writePackIndex(idxpath, idxEntries, trailer, algo); err != nil {
+		return "", "", err
+	}
+
+	return packpath, idxpath, nil
+}
@@ -0,0 +1,114 @@
+package src
+
+import "errors"
+
+// BlobBucket - the minimal bucket operations "S3Store" and "GCSStore"
+// need, so this package doesn't have to depend on any particular cloud
+// SDK. Wrap e.g. an "s3.Client" or a "storage.BucketHandle" in a small
+// adapter that implements this interface.
+type BlobBucket interface {
+	// GetObject - fetches the bytes stored under key.
+	GetObject(key string) ([]byte, error)
+	// PutObject - stores data under key, creating or overwriting it.
+	PutObject(key string, data []byte) error
+	// HasObject - reports whether key exists in the bucket.
+	HasObject(key string) bool
+	// ListObjects - lists every key stored under prefix.
+	ListObjects(prefix string) ([]string, error)
+}
+
+// bucketStore - shared logic for "S3Store" and "GCSStore": loose objects
+// keyed by "sha[:2]/sha[2:]", encoded and compressed exactly like
+// "FileStore" so objects can move between the two freely.
+type bucketStore struct {
+	bucket BlobBucket
+	algo   HashAlgo
+}
+
+func (s *bucketStore) Write(obj GitObject) (string, error) {
+	content := encodeObject(obj)
+	sha := shaOfContent(content, s.algo)
+
+	if err := s.bucket.PutObject(bucketKey(sha), deflate(content)); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+func (s *bucketStore) Read(sha string) (GitObject, error) {
+	compressed, err := s.bucket.GetObject(bucketKey(sha))
+	if err != nil {
+		return GitObject{}, err
+	}
+
+	content, err := inflate(compressed)
+	if err != nil {
+		return GitObject{}, err
+	}
+
+	return decodeObject(content), nil
+}
+
+func (s *bucketStore) Has(sha string) bool {
+	return s.bucket.HasObject(bucketKey(sha))
+}
+
+func (s *bucketStore) Iter(fn func(sha string) error) error {
+	keys, err := s.bucket.ListObjects("")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		sha, ok := shaFromBucketKey(key)
+		if !ok {
+			continue
+		}
+		if err := fn(sha); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketKey - the object key for sha, sharded the same way "FileStore"
+// shards directories: "sha[:2]/sha[2:]".
+func bucketKey(sha string) string {
+	return sha[:2] + "/" + sha[2:]
+}
+
+// shaFromBucketKey - reverses "bucketKey".
+func shaFromBucketKey(key string) (string, bool) {
+	if len(key) != 2+1+38 && len(key) != 2+1+62 {
+		return "", false
+	}
+	return key[:2] + key[3:], true
+}
+
+// S3Store - an "ObjectStore" backed by an S3-compatible bucket.
+type S3Store struct {
+	bucketStore
+}
+
+// NewS3Store - an "ObjectStore" storing loose objects in bucket, hashed
+// with algo.
+func NewS3Store(bucket BlobBucket, algo HashAlgo) (*S3Store, error) {
+	if bucket == nil {
+		return nil, errors.New("packfile: nil bucket passed to NewS3Store")
+	}
+	return &S3Store{bucketStore{bucket: bucket, algo: algo}}, nil
+}
+
+// GCSStore - an "ObjectStore" backed by a Google Cloud Storage bucket.
+type GCSStore struct {
+	bucketStore
+}
+
+// NewGCSStore - an "ObjectStore" storing loose objects in bucket, hashed
+// with algo.
+func NewGCSStore(bucket BlobBucket, algo HashAlgo) (*GCSStore, error) {
+	if bucket == nil {
+		return nil, errors.New("packfile: nil bucket passed to NewGCSStore")
+	}
+	return &GCSStore{bucketStore{bucket: bucket, algo: algo}}, nil
+}
@@ -0,0 +1,142 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"testing"
+)
+
+var errNotFound = errors.New("fakeBucket: key not found")
+
+// fakeBucket - a trivial in-memory "BlobBucket", standing in for a real
+// S3/GCS SDK client in tests.
+type fakeBucket struct {
+	objs map[string][]byte
+}
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{objs: make(map[string][]byte)}
+}
+
+func (b *fakeBucket) GetObject(key string) ([]byte, error) {
+	data, ok := b.objs[key]
+	if !ok {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func (b *fakeBucket) PutObject(key string, data []byte) error {
+	b.objs[key] = data
+	return nil
+}
+
+func (b *fakeBucket) HasObject(key string) bool {
+	_, ok := b.objs[key]
+	return ok
+}
+
+func (b *fakeBucket) ListObjects(prefix string) ([]string, error) {
+	var keys []string
+	for k := range b.objs {
+		if len(k) >= len(prefix) && k[:len(prefix)] == prefix {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func TestBucketKeyRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		sha  string
+	}{
+		{"sha1", "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
+		{"sha256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key := bucketKey(c.sha)
+
+			got, ok := shaFromBucketKey(key)
+			if !ok {
+				t.Fatalf("shaFromBucketKey(%q): want ok, got false", key)
+			}
+			if got != c.sha {
+				t.Errorf("shaFromBucketKey(%q) = %q, want %q", key, got, c.sha)
+			}
+		})
+	}
+}
+
+func TestShaFromBucketKeyRejectsOtherShapes(t *testing.T) {
+	for _, key := range []string{"", "not-a-shard-key", "ab/too-short"} {
+		if _, ok := shaFromBucketKey(key); ok {
+			t.Errorf("shaFromBucketKey(%q): want false, got true", key)
+		}
+	}
+}
+
+func TestNewS3StoreNilBucket(t *testing.T) {
+	if _, err := NewS3Store(nil, HashSHA1); err == nil {
+		t.Fatal("NewS3Store(nil, ...): want error, got nil")
+	}
+}
+
+func TestNewGCSStoreNilBucket(t *testing.T) {
+	if _, err := NewGCSStore(nil, HashSHA1); err == nil {
+		t.Fatal("NewGCSStore(nil, ...): want error, got nil")
+	}
+}
+
+// TestCloudStoresImplementObjectStore exercises S3Store and GCSStore
+// through the same Write/Read/Has/Iter sequence, demonstrating they're
+// interchangeable "ObjectStore"s (like "MemStore"/"FileStore") for any
+// caller that only depends on the interface.
+func TestCloudStoresImplementObjectStore(t *testing.T) {
+	newStores := map[string]func(BlobBucket, HashAlgo) (ObjectStore, error){
+		"S3Store":  func(b BlobBucket, a HashAlgo) (ObjectStore, error) { return NewS3Store(b, a) },
+		"GCSStore": func(b BlobBucket, a HashAlgo) (ObjectStore, error) { return NewGCSStore(b, a) },
+	}
+
+	for name, newStore := range newStores {
+		t.Run(name, func(t *testing.T) {
+			store, err := newStore(newFakeBucket(), HashSHA1)
+			if err != nil {
+				t.Fatalf("construct: %v", err)
+			}
+
+			obj := GitObject{Kind: "blob", Data: []byte("cloud-backed object")}
+			sha, err := store.Write(obj)
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			if !store.Has(sha) {
+				t.Fatalf("Has(%s): want true, got false", sha)
+			}
+
+			got, err := store.Read(sha)
+			if err != nil {
+				t.Fatalf("Read(%s): %v", sha, err)
+			}
+			if got.Kind != obj.Kind || !bytes.Equal(got.Data, obj.Data) {
+				t.Errorf("Read(%s) = %+v, want %+v", sha, got, obj)
+			}
+
+			var seen []string
+			if err := store.Iter(func(sha string) error {
+				seen = append(seen, sha)
+				return nil
+			}); err != nil {
+				t.Fatalf("Iter: %v", err)
+			}
+			if len(seen) != 1 || seen[0] != sha {
+				t.Errorf("Iter yielded %v, want [%s]", seen, sha)
+			}
+		})
+	}
+}
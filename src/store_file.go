@@ -0,0 +1,131 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/ritwik310/a-git/src/objfile"
+)
+
+// FileStore - an "ObjectStore" backed by the on-disk loose object layout
+// under "<gitdir>/objects/xx/yyyy...". Object ids are hashed with Algo
+// (40 hex chars for "HashSHA1", 64 for "HashSHA256") but are otherwise
+// sharded by their first two hex chars either way.
+type FileStore struct {
+	Gitdir string
+	Algo   HashAlgo
+}
+
+// NewFileStore - an "ObjectStore" rooted at gitdir, hashing objects with
+// the algorithm declared by "extensions.objectFormat" in gitdir's config
+// (SHA-1 if unset).
+func NewFileStore(gitdir string) (*FileStore, error) {
+	algo, err := RepoHashAlgo(gitdir)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{Gitdir: gitdir, Algo: algo}, nil
+}
+
+// Write - writes obj as a compressed loose object file, named after its
+// content hash, via "objfile.Writer".
+func (s *FileStore) Write(obj GitObject) (string, error) {
+	return s.WriteStream(obj.Kind, int64(len(obj.Data)), bytes.NewReader(obj.Data))
+}
+
+// WriteStream - streams size bytes of kind-object payload from r straight
+// into a compressed loose object file, without holding the whole payload
+// in memory - the entry point to use for multi-GB blobs.
+func (s *FileStore) WriteStream(kind string, size int64, r io.Reader) (string, error) {
+	w, err := objfile.NewWriter(s.Gitdir, kind, size, s.Algo.New)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return w.Sha(), nil
+}
+
+// Read - reads and decodes the loose object identified by sha.
+func (s *FileStore) Read(sha string) (GitObject, error) {
+	kind, size, rc, err := s.ReadStream(sha)
+	if err != nil {
+		return GitObject{}, err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return GitObject{}, err
+	}
+
+	return GitObject{Kind: kind, Size: strconv.FormatInt(size, 10), Data: data}, nil
+}
+
+// ReadStream - opens the loose object identified by sha for streaming,
+// without reading its payload fully into memory - the entry point to use
+// for multi-GB blobs. The caller must Close the returned reader.
+func (s *FileStore) ReadStream(sha string) (kind string, size int64, rc io.ReadCloser, err error) {
+	objectpath := s.path(sha)
+	if _, err := os.Stat(objectpath); os.IsNotExist(err) {
+		return "", 0, nil, errors.New("Specifies file does not exist")
+	}
+
+	r, err := objfile.NewReader(objectpath)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	kind, size = r.Header()
+	return kind, size, r, nil
+}
+
+// Has - reports whether a loose object with the given sha exists on disk.
+func (s *FileStore) Has(sha string) bool {
+	_, err := os.Stat(s.path(sha))
+	return err == nil
+}
+
+// Iter - walks "objects/xx/yyyy..." calling fn with each reconstructed id.
+func (s *FileStore) Iter(fn func(sha string) error) error {
+	root := path.Join(s.Gitdir, "objects")
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range entries {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(path.Join(root, dir.Name()))
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if err := fn(dir.Name() + f.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// path - the on-disk path for a loose object's sha under this store's gitdir.
+func (s *FileStore) path(sha string) string {
+	return path.Join(s.Gitdir, "objects", sha[:2], sha[2:])
+}
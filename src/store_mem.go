@@ -0,0 +1,49 @@
+package src
+
+import "errors"
+
+// MemStore - an in-memory "ObjectStore", mainly useful for tests.
+type MemStore struct {
+	Algo HashAlgo
+	objs map[string]GitObject
+}
+
+// NewMemStore - an empty, ready-to-use in-memory "ObjectStore" that
+// hashes objects with algo.
+func NewMemStore(algo HashAlgo) *MemStore {
+	return &MemStore{Algo: algo, objs: make(map[string]GitObject)}
+}
+
+// Write - stores obj under its id, overwriting any prior object with
+// the same id.
+func (s *MemStore) Write(obj GitObject) (string, error) {
+	content := encodeObject(obj)
+	sha := shaOfContent(content, s.Algo)
+	s.objs[sha] = obj
+	return sha, nil
+}
+
+// Read - fetches the object stored under sha.
+func (s *MemStore) Read(sha string) (GitObject, error) {
+	obj, ok := s.objs[sha]
+	if !ok {
+		return GitObject{}, errors.New("Specifies file does not exist")
+	}
+	return obj, nil
+}
+
+// Has - reports whether an object with the given id is present.
+func (s *MemStore) Has(sha string) bool {
+	_, ok := s.objs[sha]
+	return ok
+}
+
+// Iter - calls fn once per stored id, stopping at the first error.
+func (s *MemStore) Iter(fn func(sha string) error) error {
+	for sha := range s.objs {
+		if err := fn(sha); err != nil {
+			return err
+		}
+	}
+	return nil
+}